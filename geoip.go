@@ -14,83 +14,173 @@ import "C"
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
+	"time"
 	"unsafe"
 )
 
 type GeoIP struct {
-	db *C.GeoIP
-	mu sync.Mutex
+	db   *C.GeoIP
+	mmdb *mmdbGeoIP
+	mu   sync.Mutex
+
+	// path and loadedAt track the file this GeoIP was opened from, so
+	// Watch can tell when MaxMind has published an update. Set by Open,
+	// read and updated under mu by the watch goroutine.
+	path      string
+	loadedAt  time.Time
+	stopWatch chan struct{}
+	watchDone chan struct{}
+
+	// closed is set by Close, under mu, before it frees gi.db/gi.mmdb. The
+	// watch goroutine's reload paths check it after reopening a new
+	// database file so a reload racing a Close discards the handle it just
+	// opened instead of installing it into (or freeing it alongside) a gi
+	// that's already being torn down.
+	closed bool
 }
 
+// free releases gi's underlying database handle(s). It's safe to call more
+// than once: it nils gi.db/gi.mmdb after releasing them, so a second call
+// (from both the finalizer and an explicit Close, say) is a no-op instead of
+// a double free.
 func (gi *GeoIP) free() {
 	if gi == nil {
 		return
 	}
-	if gi.db == nil {
-		gi = nil
-		return
+	if gi.mmdb != nil {
+		gi.mmdb.close()
+		gi.mmdb = nil
+	}
+	if gi.db != nil {
+		C.GeoIP_delete(gi.db)
+		gi.db = nil
 	}
-	C.GeoIP_delete(gi.db)
-	gi = nil
-	return
 }
 
 // Opens a GeoIP database, all formats supported by libgeoip are supported though
-// there are only functions to access some of the databases in this API.
+// there are only functions to access some of the databases in this API. MaxMind
+// DB (.mmdb) files, as used by the GeoLite2/GeoIP2 databases, are also supported,
+// via a pure-Go backend; the format is auto-detected so callers don't need to
+// care which one they pointed Open at.
 // The database is opened in MEMORY_CACHE mode, if you need to optimize for memory
 // instead of performance you should change this.
-// If you don't pass a filename, it will try opening the database from
-// a list of common paths.
+// If you don't pass a filename, it will try opening the database from a list of
+// common paths, stopping at the first one that opens successfully. The GeoLite2
+// City database is listed ahead of GeoLite2 Country in each directory since it's
+// a superset of the Country data: if both are installed, GetRecord/GetRegion
+// still work, whereas latching onto Country first would make them always fail.
+// The legacy GeoIP.dat (a Country database) is still tried before either of them
+// in each directory, so when it is present it is the one opened.
 func Open(files ...string) (*GeoIP, error) {
 	if len(files) == 0 {
 		files = []string{
-			"/usr/share/GeoIP/GeoIP.dat",       // Linux default
-			"/usr/share/local/GeoIP/GeoIP.dat", // source install?
-			"/usr/local/share/GeoIP/GeoIP.dat", // FreeBSD
-			"/opt/local/share/GeoIP/GeoIP.dat", // MacPorts
-			"/usr/share/GeoIP/GeoIP.dat",       // ArchLinux
+			"/usr/share/GeoIP/GeoIP.dat",                   // Linux default
+			"/usr/share/GeoIP/GeoLite2-City.mmdb",          // Linux, MaxMind GeoLite2
+			"/usr/share/GeoIP/GeoLite2-Country.mmdb",       // Linux, MaxMind GeoLite2
+			"/usr/share/GeoIP/GeoLite2-ASN.mmdb",           // Linux, MaxMind GeoLite2
+			"/usr/share/local/GeoIP/GeoIP.dat",             // source install?
+			"/usr/local/share/GeoIP/GeoIP.dat",             // FreeBSD
+			"/usr/local/share/GeoIP/GeoLite2-City.mmdb",    // FreeBSD
+			"/usr/local/share/GeoIP/GeoLite2-Country.mmdb", // FreeBSD
+			"/usr/local/share/GeoIP/GeoLite2-ASN.mmdb",     // FreeBSD
+			"/opt/local/share/GeoIP/GeoIP.dat",             // MacPorts
+			"/opt/local/share/GeoIP/GeoLite2-City.mmdb",    // MacPorts
+			"/opt/local/share/GeoIP/GeoLite2-Country.mmdb", // MacPorts
+			"/opt/local/share/GeoIP/GeoLite2-ASN.mmdb",     // MacPorts
+			"/usr/share/GeoIP/GeoIP.dat",                   // ArchLinux
 		}
 	}
 
 	g := &GeoIP{}
 	runtime.SetFinalizer(g, (*GeoIP).free)
 
-	var err error
+	openErr := &OpenError{}
 
 	for _, file := range files {
+		fi, statErr := os.Stat(file)
+		if statErr != nil {
+			openErr.Attempts = append(openErr.Attempts, OpenAttempt{Path: file, Err: statErr})
+			continue
+		}
 
-		// libgeoip prints errors if it can't open the file, so check first
-		if _, err := os.Stat(file); err != nil {
-			if os.IsExist(err) {
-				log.Println(err)
+		if isMaxMindDB(file) {
+			mmdb, err := openMMDB(file)
+			if err != nil {
+				openErr.Attempts = append(openErr.Attempts, OpenAttempt{Path: file, Err: err})
+				continue
 			}
-			continue
+			g.mmdb = mmdb
+			g.path = file
+			g.loadedAt = fi.ModTime()
+			break
 		}
 
 		cbase := C.CString(file)
-		defer C.free(unsafe.Pointer(cbase))
+		db, err := C.GeoIP_open(cbase, C.GEOIP_MEMORY_CACHE)
+		C.free(unsafe.Pointer(cbase))
 
-		g.db, err = C.GeoIP_open(cbase, C.GEOIP_MEMORY_CACHE)
-		if g.db != nil && err != nil {
-			break
+		if db == nil || err != nil {
+			openErr.Attempts = append(openErr.Attempts, OpenAttempt{Path: file, Err: err})
+			continue
 		}
-	}
-	if err != nil {
-		return nil, fmt.Errorf("Error opening GeoIP database (%s): %s", files, err)
+
+		g.db = db
+		g.path = file
+		g.loadedAt = fi.ModTime()
+		break
 	}
 
-	if g.db == nil {
-		return nil, fmt.Errorf("Didn't open GeoIP database (%s)", files)
+	if g.db == nil && g.mmdb == nil {
+		return nil, openErr
 	}
 
-	C.GeoIP_set_charset(g.db, C.GEOIP_CHARSET_UTF8)
+	if g.db != nil {
+		C.GeoIP_set_charset(g.db, C.GEOIP_CHARSET_UTF8)
+	}
 	return g, nil
 }
 
+// OpenAttempt records why Open failed to use one candidate database path.
+type OpenAttempt struct {
+	Path string
+	Err  error
+}
+
+// OpenError is returned by Open when none of the candidate database files
+// could be opened. It records the specific failure for every path
+// attempted, so callers hitting a misconfigured install get more than a
+// generic "didn't open" message.
+type OpenError struct {
+	Attempts []OpenAttempt
+}
+
+func (e *OpenError) Error() string {
+	if len(e.Attempts) == 0 {
+		return "geoip: no candidate database paths"
+	}
+
+	var b strings.Builder
+	fmt.Fprint(&b, "geoip: couldn't open a database, tried:")
+	for _, a := range e.Attempts {
+		fmt.Fprintf(&b, "\n  %s: %v", a.Path, a.Err)
+	}
+	return b.String()
+}
+
+// Unwrap exposes each path's error to errors.Is and errors.As, following
+// the errors.Join convention for multi-error wrapping.
+func (e *OpenError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, a := range e.Attempts {
+		errs[i] = a.Err
+	}
+	return errs
+}
+
 // Takes an IPv4 address string and returns the organization name for that IP.
 // Requires the GeoIP organization database.
 func (gi *GeoIP) GetOrg(ip string) string {
@@ -102,6 +192,12 @@ func (gi *GeoIP) GetOrg(ip string) string {
 // databases, takes and IP string and returns a "name" and the
 // netmask.
 func (gi *GeoIP) GetName(ip string) (name string, netmask int) {
+	gi.mu.Lock() // Lock for the duration of the call so Watch can't swap/free gi.db or gi.mmdb under us
+	defer gi.mu.Unlock()
+
+	if gi.mmdb != nil {
+		return gi.mmdb.getName(ip)
+	}
 	if gi.db == nil {
 		return
 	}
@@ -121,6 +217,12 @@ func (gi *GeoIP) GetName(ip string) (name string, netmask int) {
 
 // Same as GetName() but for IPv6 addresses.
 func (gi *GeoIP) GetNameV6(ip string) (name string, netmask int) {
+	gi.mu.Lock() // Lock for the duration of the call so Watch can't swap/free gi.db or gi.mmdb under us
+	defer gi.mu.Unlock()
+
+	if gi.mmdb != nil {
+		return gi.mmdb.getName(ip)
+	}
 	if gi.db == nil {
 		return
 	}
@@ -141,13 +243,16 @@ func (gi *GeoIP) GetNameV6(ip string) (name string, netmask int) {
 // Takes an IPv4 address string and returns the country code for that IP
 // and the netmask for that IP range.
 func (gi *GeoIP) GetCountry(ip string) (cc string, netmask int) {
+	gi.mu.Lock() // Lock to make sure we get the right result from GeoIP_last_netmask, and so Watch can't swap/free gi.db or gi.mmdb under us
+	defer gi.mu.Unlock()
+
+	if gi.mmdb != nil {
+		return gi.mmdb.getCountry(ip)
+	}
 	if gi.db == nil {
 		return
 	}
 
-	gi.mu.Lock() // Lock to make sure we get the right result from GeoIP_last_netmask
-	defer gi.mu.Unlock()
-
 	cip := C.CString(ip)
 	defer C.free(unsafe.Pointer(cip))
 	ccountry := C.GeoIP_country_code_by_addr(gi.db, cip)
@@ -163,6 +268,12 @@ func (gi *GeoIP) GetCountry(ip string) (cc string, netmask int) {
 // GetCountry_v6 works the same as GetCountry except for IPv6 addresses, be sure to
 // load a database with IPv6 data to get any results.
 func (gi *GeoIP) GetCountry_v6(ip string) (cc string, netmask int) {
+	gi.mu.Lock() // Lock for the duration of the call so Watch can't swap/free gi.db or gi.mmdb under us
+	defer gi.mu.Unlock()
+
+	if gi.mmdb != nil {
+		return gi.mmdb.getCountry(ip)
+	}
 	if gi.db == nil {
 		return
 	}
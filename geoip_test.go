@@ -0,0 +1,37 @@
+package geoip
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOpenErrorError(t *testing.T) {
+	e := &OpenError{Attempts: []OpenAttempt{
+		{Path: "/no/such/file.dat", Err: errors.New("no such file or directory")},
+		{Path: "/other/file.mmdb", Err: errors.New("permission denied")},
+	}}
+
+	msg := e.Error()
+	for _, want := range []string{"/no/such/file.dat", "no such file or directory", "/other/file.mmdb", "permission denied"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Error() = %q, missing %q", msg, want)
+		}
+	}
+}
+
+func TestOpenErrorUnwrap(t *testing.T) {
+	notFound := errors.New("not found")
+	e := &OpenError{Attempts: []OpenAttempt{{Path: "/a", Err: notFound}}}
+
+	if !errors.Is(e, notFound) {
+		t.Error("errors.Is(e, notFound) = false, want true")
+	}
+}
+
+func TestOpenErrorEmpty(t *testing.T) {
+	e := &OpenError{}
+	if e.Error() == "" {
+		t.Error("Error() on an empty OpenError returned an empty string")
+	}
+}
@@ -0,0 +1,147 @@
+package geoip
+
+// Pure-Go backend for MaxMind DB (.mmdb) files, used by the GeoLite2 and
+// GeoIP2 databases that have replaced the legacy .dat format. This backend
+// needs no cgo, so Open swaps it in transparently when it recognises the
+// file as a MaxMind DB.
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// maxMindDBMetadataMarker is the byte sequence maxminddb-golang itself
+// looks for to find the metadata section, which always lives near the end
+// of the file.
+const maxMindDBMetadataMarker = "\xab\xcd\xefMaxMind.com"
+
+// maxMetadataScan bounds how much of the file's tail we read while probing
+// for the metadata marker.
+const maxMetadataScan = 128 * 1024
+
+// isMaxMindDB reports whether file looks like a MaxMind DB rather than a
+// legacy libgeoip .dat database.
+func isMaxMindDB(file string) bool {
+	if strings.HasSuffix(strings.ToLower(file), ".mmdb") {
+		return true
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 {
+		return false
+	}
+
+	readSize := int64(maxMetadataScan)
+	if readSize > fi.Size() {
+		readSize = fi.Size()
+	}
+	buf := make([]byte, readSize)
+	if _, err := f.ReadAt(buf, fi.Size()-readSize); err != nil {
+		return false
+	}
+	return bytes.Contains(buf, []byte(maxMindDBMetadataMarker))
+}
+
+// mmdbGeoIP is the MaxMind DB backend. It's embedded in GeoIP rather than
+// exposed on its own, so callers keep using the familiar GeoIP/Open API
+// regardless of which database format they pointed it at.
+type mmdbGeoIP struct {
+	reader *geoip2.Reader
+}
+
+func openMMDB(file string) (*mmdbGeoIP, error) {
+	reader, err := geoip2.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	return &mmdbGeoIP{reader: reader}, nil
+}
+
+func (m *mmdbGeoIP) close() {
+	if m == nil || m.reader == nil {
+		return
+	}
+	m.reader.Close()
+}
+
+// getCountry and getName always return a netmask of 0: this backend doesn't
+// report the prefix length of the network that matched.
+func (m *mmdbGeoIP) getCountry(ip string) (cc string, netmask int) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return
+	}
+	country, err := m.reader.Country(parsed)
+	if err != nil {
+		return
+	}
+	return country.Country.IsoCode, 0
+}
+
+func (m *mmdbGeoIP) getName(ip string) (name string, netmask int) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return
+	}
+	asn, err := m.reader.ASN(parsed)
+	if err != nil {
+		return
+	}
+	return asn.AutonomousSystemOrganization, 0
+}
+
+func (m *mmdbGeoIP) getRegion(ip string) (*Region, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("geoip: %q is not a valid IP address", ip)
+	}
+
+	city, err := m.reader.City(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Region{CountryCode: city.Country.IsoCode}
+	if len(city.Subdivisions) > 0 {
+		r.Region = city.Subdivisions[0].IsoCode
+	}
+	return r, nil
+}
+
+func (m *mmdbGeoIP) getRecord(ip string) (*Record, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("geoip: %q is not a valid IP address", ip)
+	}
+
+	city, err := m.reader.City(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Record{
+		CountryCode:   city.Country.IsoCode,
+		CountryName:   city.Country.Names["en"],
+		City:          city.City.Names["en"],
+		PostalCode:    city.Postal.Code,
+		Latitude:      float32(city.Location.Latitude),
+		Longitude:     float32(city.Location.Longitude),
+		MetroCode:     int(city.Location.MetroCode),
+		ContinentCode: city.Continent.Code,
+	}
+	if len(city.Subdivisions) > 0 {
+		r.Region = city.Subdivisions[0].IsoCode
+	}
+	return r, nil
+}
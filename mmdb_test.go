@@ -0,0 +1,199 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// buildTestCityDB writes a minimal GeoLite2-City-shaped MaxMind DB to a temp
+// file and returns its path, so isMaxMindDB/openMMDB/getCountry/getRecord/
+// getRegion can be exercised without a real MaxMind license.
+func buildTestCityDB(t *testing.T) string {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            "GeoLite2-City",
+		RecordSize:              24,
+		BuildEpoch:              1,
+		IncludeReservedNetworks: true,
+	})
+	if err != nil {
+		t.Fatalf("mmdbwriter.New: %v", err)
+	}
+
+	_, network, err := net.ParseCIDR("203.0.113.0/24")
+	if err != nil {
+		t.Fatalf("ParseCIDR: %v", err)
+	}
+
+	record := mmdbtype.Map{
+		"country": mmdbtype.Map{
+			"iso_code": mmdbtype.String("AU"),
+		},
+		"city": mmdbtype.Map{
+			"names": mmdbtype.Map{"en": mmdbtype.String("Sydney")},
+		},
+		"postal": mmdbtype.Map{
+			"code": mmdbtype.String("2000"),
+		},
+		"location": mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(-33.8688),
+			"longitude": mmdbtype.Float64(151.2093),
+		},
+		"continent": mmdbtype.Map{
+			"code": mmdbtype.String("OC"),
+		},
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{"iso_code": mmdbtype.String("NSW")},
+		},
+	}
+
+	if err := tree.Insert(network, record); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "GeoLite2-City-Test.mmdb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := tree.WriteTo(f); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+
+	return path
+}
+
+// buildTestASNDB writes a minimal GeoLite2-ASN-shaped MaxMind DB, for
+// exercising getName.
+func buildTestASNDB(t *testing.T) string {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType:            "GeoLite2-ASN",
+		RecordSize:              24,
+		BuildEpoch:              1,
+		IncludeReservedNetworks: true,
+	})
+	if err != nil {
+		t.Fatalf("mmdbwriter.New: %v", err)
+	}
+
+	_, network, err := net.ParseCIDR("203.0.113.0/24")
+	if err != nil {
+		t.Fatalf("ParseCIDR: %v", err)
+	}
+
+	record := mmdbtype.Map{
+		"autonomous_system_number":       mmdbtype.Uint32(64512),
+		"autonomous_system_organization": mmdbtype.String("Example Org"),
+	}
+
+	if err := tree.Insert(network, record); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "GeoLite2-ASN-Test.mmdb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := tree.WriteTo(f); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+
+	return path
+}
+
+func TestIsMaxMindDB(t *testing.T) {
+	cityDB := buildTestCityDB(t)
+	if !isMaxMindDB(cityDB) {
+		t.Errorf("isMaxMindDB(%q) = false, want true", cityDB)
+	}
+
+	notMMDB := filepath.Join(t.TempDir(), "GeoIP.dat")
+	if err := os.WriteFile(notMMDB, []byte("not a MaxMind DB"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if isMaxMindDB(notMMDB) {
+		t.Errorf("isMaxMindDB(%q) = true, want false", notMMDB)
+	}
+
+	// A .mmdb extension should be trusted even without a readable marker.
+	extOnly := filepath.Join(t.TempDir(), "empty.mmdb")
+	if err := os.WriteFile(extOnly, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !isMaxMindDB(extOnly) {
+		t.Errorf("isMaxMindDB(%q) = false, want true (by extension)", extOnly)
+	}
+
+	if isMaxMindDB(filepath.Join(t.TempDir(), "does-not-exist.dat")) {
+		t.Error("isMaxMindDB on a missing file = true, want false")
+	}
+}
+
+func TestMMDBGeoIPGetCountryAndRecord(t *testing.T) {
+	m, err := openMMDB(buildTestCityDB(t))
+	if err != nil {
+		t.Fatalf("openMMDB: %v", err)
+	}
+	defer m.close()
+
+	if cc, _ := m.getCountry("203.0.113.5"); cc != "AU" {
+		t.Errorf("getCountry = %q, want AU", cc)
+	}
+	if cc, _ := m.getCountry("8.8.8.8"); cc != "" {
+		t.Errorf("getCountry outside the loaded network = %q, want empty", cc)
+	}
+
+	rec, err := m.getRecord("203.0.113.5")
+	if err != nil {
+		t.Fatalf("getRecord: %v", err)
+	}
+	if rec.CountryCode != "AU" || rec.City != "Sydney" || rec.Region != "NSW" || rec.PostalCode != "2000" {
+		t.Errorf("getRecord = %+v, want AU/Sydney/NSW/2000", rec)
+	}
+
+	region, err := m.getRegion("203.0.113.5")
+	if err != nil {
+		t.Fatalf("getRegion: %v", err)
+	}
+	if region.CountryCode != "AU" || region.Region != "NSW" {
+		t.Errorf("getRegion = %+v, want AU/NSW", region)
+	}
+}
+
+func TestMMDBGeoIPGetName(t *testing.T) {
+	m, err := openMMDB(buildTestASNDB(t))
+	if err != nil {
+		t.Fatalf("openMMDB: %v", err)
+	}
+	defer m.close()
+
+	if name, _ := m.getName("203.0.113.5"); name != "Example Org" {
+		t.Errorf("getName = %q, want Example Org", name)
+	}
+	if name, _ := m.getName("8.8.8.8"); name != "" {
+		t.Errorf("getName outside the loaded network = %q, want empty", name)
+	}
+}
+
+func TestOpenMMDBInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "garbage.mmdb")
+	if err := os.WriteFile(path, []byte("not a real database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openMMDB(path); err == nil {
+		t.Fatal("openMMDB on a garbage file: expected an error, got nil")
+	}
+}
@@ -0,0 +1,24 @@
+package geoip
+
+// Provider is the common interface implemented by every geoip backend:
+// GeoIP (libgeoip and MaxMind DB files) and TorProvider (Tor-format geoip
+// range files). Code that only needs lookups, not the specifics of Open,
+// can depend on Provider instead of the concrete GeoIP type, which makes
+// it trivial to substitute TorProvider in tests.
+type Provider interface {
+	GetCountry(ip string) (cc string, netmask int)
+	GetCountryV6(ip string) (cc string, netmask int)
+	GetName(ip string) (name string, netmask int)
+	GetRecord(ip string) (*Record, error)
+	Close()
+}
+
+var (
+	_ Provider = (*GeoIP)(nil)
+	_ Provider = (*TorProvider)(nil)
+)
+
+// GetCountryV6 is GetCountry_v6 under the name Provider requires.
+func (gi *GeoIP) GetCountryV6(ip string) (cc string, netmask int) {
+	return gi.GetCountry_v6(ip)
+}
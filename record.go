@@ -0,0 +1,136 @@
+package geoip
+
+/*
+#cgo CFLAGS: -I/opt/local/include -I/usr/local/include -I/usr/include
+#cgo LDFLAGS: -lGeoIP -L/opt/local/lib -L/usr/local/lib -L/usr/lib
+#include <GeoIP.h>
+#include <GeoIPCity.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Record holds the location details associated with an IP address, as
+// returned by the GeoIP City database.
+type Record struct {
+	CountryCode   string
+	CountryCode3  string
+	CountryName   string
+	Region        string
+	City          string
+	PostalCode    string
+	Latitude      float32
+	Longitude     float32
+	MetroCode     int
+	AreaCode      int
+	ContinentCode string
+}
+
+func newRecord(cr *C.GeoIPRecord) *Record {
+	if cr == nil {
+		return nil
+	}
+	return &Record{
+		CountryCode:   C.GoString(cr.country_code),
+		CountryCode3:  C.GoString(cr.country_code3),
+		CountryName:   C.GoString(cr.country_name),
+		Region:        C.GoString(cr.region),
+		City:          C.GoString(cr.city),
+		PostalCode:    C.GoString(cr.postal_code),
+		Latitude:      float32(cr.latitude),
+		Longitude:     float32(cr.longitude),
+		MetroCode:     int(cr.metro_code),
+		AreaCode:      int(cr.area_code),
+		ContinentCode: C.GoString(cr.continent_code),
+	}
+}
+
+// GetRecord takes an IPv4 address string and returns the City record for
+// that IP, including region, city, postal code and latitude/longitude.
+// Requires the GeoIP City database.
+func (gi *GeoIP) GetRecord(ip string) (*Record, error) {
+	gi.mu.Lock() // Lock for the duration of the call so Watch can't swap/free gi.db or gi.mmdb under us
+	defer gi.mu.Unlock()
+
+	if gi.mmdb != nil {
+		return gi.mmdb.getRecord(ip)
+	}
+	if gi.db == nil {
+		return nil, errors.New("geoip: no database open")
+	}
+
+	cip := C.CString(ip)
+	defer C.free(unsafe.Pointer(cip))
+
+	cr := C.GeoIP_record_by_addr(gi.db, cip)
+	if cr == nil {
+		return nil, fmt.Errorf("geoip: no record found for %s", ip)
+	}
+	defer C.GeoIP_record_delete(cr)
+
+	return newRecord(cr), nil
+}
+
+// GetRecordV6 is the IPv6 equivalent of GetRecord.
+func (gi *GeoIP) GetRecordV6(ip string) (*Record, error) {
+	gi.mu.Lock() // Lock for the duration of the call so Watch can't swap/free gi.db or gi.mmdb under us
+	defer gi.mu.Unlock()
+
+	if gi.mmdb != nil {
+		return gi.mmdb.getRecord(ip)
+	}
+	if gi.db == nil {
+		return nil, errors.New("geoip: no database open")
+	}
+
+	cip := C.CString(ip)
+	defer C.free(unsafe.Pointer(cip))
+
+	cr := C.GeoIP_record_by_addr_v6(gi.db, cip)
+	if cr == nil {
+		return nil, fmt.Errorf("geoip: no record found for %s", ip)
+	}
+	defer C.GeoIP_record_delete(cr)
+
+	return newRecord(cr), nil
+}
+
+// Region holds the country and region (state/province) for an IP address,
+// as returned by the GeoIP Region database.
+type Region struct {
+	CountryCode string
+	Region      string
+}
+
+// GetRegion takes an IPv4 address string and returns the country and
+// region for that IP. Requires the GeoIP Region database, or a MaxMind DB
+// with city-level data (the region is read from its subdivisions).
+func (gi *GeoIP) GetRegion(ip string) (*Region, error) {
+	gi.mu.Lock() // Lock for the duration of the call so Watch can't swap/free gi.db or gi.mmdb under us
+	defer gi.mu.Unlock()
+
+	if gi.mmdb != nil {
+		return gi.mmdb.getRegion(ip)
+	}
+	if gi.db == nil {
+		return nil, errors.New("geoip: no database open")
+	}
+
+	cip := C.CString(ip)
+	defer C.free(unsafe.Pointer(cip))
+
+	cr := C.GeoIP_region_by_addr(gi.db, cip)
+	if cr == nil {
+		return nil, fmt.Errorf("geoip: no region found for %s", ip)
+	}
+	defer C.GeoIP_region_delete(cr)
+
+	return &Region{
+		CountryCode: C.GoString(&cr.country_code[0]),
+		Region:      C.GoString(&cr.region[0]),
+	}, nil
+}
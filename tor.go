@@ -0,0 +1,257 @@
+package geoip
+
+// TorProvider is a dependency-free, cgo-free Provider backed by Tor's
+// geoip/geoip6 range files: sorted ranges of INTIPLOW,INTIPHIGH,CC for
+// IPv4 and IPV6LOW,IPV6HIGH,CC for IPv6 (see
+// https://gitlab.torproject.org/tpo/core/tor/-/blob/main/src/config/geoip).
+// It's useful for unit tests, and for anti-censorship tooling where Tor's
+// own geoip files are the source of truth rather than MaxMind's.
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ipv4Range is a contiguous range of IPv4 addresses sharing a country
+// code, stored as the big-endian uint32 form of its bounds.
+type ipv4Range struct {
+	lo, hi uint32
+	cc     string
+}
+
+// ipv6Range is the IPv6 equivalent of ipv4Range.
+type ipv6Range struct {
+	lo, hi [16]byte
+	cc     string
+}
+
+// TorProvider implements Provider by holding the parsed range tables in
+// memory and binary-searching them.
+type TorProvider struct {
+	v4 []ipv4Range
+	v6 []ipv6Range
+}
+
+// OpenTorGeoIP loads a Tor-format IPv4 geoip file, IPv6 geoip6 file, or
+// both; pass "" for whichever one you don't have.
+func OpenTorGeoIP(v4File, v6File string) (*TorProvider, error) {
+	t := &TorProvider{}
+
+	if v4File != "" {
+		v4, err := parseTorIPv4File(v4File)
+		if err != nil {
+			return nil, err
+		}
+		t.v4 = v4
+	}
+
+	if v6File != "" {
+		v6, err := parseTorIPv6File(v6File)
+		if err != nil {
+			return nil, err
+		}
+		t.v6 = v6
+	}
+
+	return t, nil
+}
+
+func parseTorIPv4File(file string) ([]ipv4Range, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ranges []ipv4Range
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ",", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		lo, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("geoip: bad low address %q in %s: %w", parts[0], file, err)
+		}
+		hi, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("geoip: bad high address %q in %s: %w", parts[1], file, err)
+		}
+
+		ranges = append(ranges, ipv4Range{lo: uint32(lo), hi: uint32(hi), cc: strings.ToUpper(parts[2])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+	return ranges, nil
+}
+
+func parseTorIPv6File(file string) ([]ipv6Range, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ranges []ipv6Range
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ",", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		lo, err := parseIPv6Bytes(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("geoip: bad low address %q in %s: %w", parts[0], file, err)
+		}
+		hi, err := parseIPv6Bytes(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("geoip: bad high address %q in %s: %w", parts[1], file, err)
+		}
+
+		ranges = append(ranges, ipv6Range{lo: lo, hi: hi, cc: strings.ToUpper(parts[2])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return bytes.Compare(ranges[i].lo[:], ranges[j].lo[:]) < 0 })
+	return ranges, nil
+}
+
+func parseIPv6Bytes(s string) (out [16]byte, err error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return out, fmt.Errorf("not an IP address")
+	}
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return out, fmt.Errorf("not an IPv6 address")
+	}
+	copy(out[:], ip16)
+	return out, nil
+}
+
+func ipv4ToUint32(ip net.IP) (uint32, bool) {
+	v4 := ip.To4()
+	if v4 == nil {
+		return 0, false
+	}
+	return uint32(v4[0])<<24 | uint32(v4[1])<<16 | uint32(v4[2])<<8 | uint32(v4[3]), true
+}
+
+// netmaskFromRangeWidth derives a synthetic netmask from a range's bounds,
+// for parity with libgeoip's netmask return value: the number of leading
+// bits lo and hi have in common.
+func netmaskFromRangeWidth(lo, hi uint32, bits int) int {
+	diff := lo ^ hi
+	mask := bits
+	for diff != 0 {
+		diff >>= 1
+		mask--
+	}
+	return mask
+}
+
+func netmask128FromRangeWidth(lo, hi [16]byte) int {
+	mask := 0
+	for i := 0; i < 16; i++ {
+		diff := lo[i] ^ hi[i]
+		mask += 8
+		if diff == 0 {
+			continue
+		}
+		for diff != 0 {
+			diff >>= 1
+			mask--
+		}
+		break
+	}
+	return mask
+}
+
+func (t *TorProvider) lookupV4(ip net.IP) (cc string, netmask int) {
+	addr, ok := ipv4ToUint32(ip)
+	if !ok || len(t.v4) == 0 {
+		return
+	}
+
+	i := sort.Search(len(t.v4), func(i int) bool { return t.v4[i].hi >= addr })
+	if i == len(t.v4) || addr < t.v4[i].lo {
+		return
+	}
+
+	r := t.v4[i]
+	return r.cc, netmaskFromRangeWidth(r.lo, r.hi, 32)
+}
+
+func (t *TorProvider) lookupV6(ip net.IP) (cc string, netmask int) {
+	addr := ip.To16()
+	if addr == nil || len(t.v6) == 0 {
+		return
+	}
+	var key [16]byte
+	copy(key[:], addr)
+
+	i := sort.Search(len(t.v6), func(i int) bool { return bytes.Compare(t.v6[i].hi[:], key[:]) >= 0 })
+	if i == len(t.v6) || bytes.Compare(key[:], t.v6[i].lo[:]) < 0 {
+		return
+	}
+
+	r := t.v6[i]
+	return r.cc, netmask128FromRangeWidth(r.lo, r.hi)
+}
+
+// GetCountry implements Provider.
+func (t *TorProvider) GetCountry(ip string) (cc string, netmask int) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return
+	}
+	return t.lookupV4(parsed)
+}
+
+// GetCountryV6 implements Provider.
+func (t *TorProvider) GetCountryV6(ip string) (cc string, netmask int) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return
+	}
+	return t.lookupV6(parsed)
+}
+
+// GetName implements Provider. Tor's geoip files don't carry organization
+// names, so this always returns an empty name.
+func (t *TorProvider) GetName(ip string) (name string, netmask int) {
+	return "", 0
+}
+
+// GetRecord implements Provider. Tor's geoip files don't carry city-level
+// data, so this always returns an error.
+func (t *TorProvider) GetRecord(ip string) (*Record, error) {
+	return nil, errors.New("geoip: city records are not available from Tor-format geoip files")
+}
+
+// Close implements Provider. TorProvider holds no resources that need
+// releasing.
+func (t *TorProvider) Close() {}
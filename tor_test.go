@@ -0,0 +1,76 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTorFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+	return path
+}
+
+func TestTorProviderGetCountry(t *testing.T) {
+	v4File := writeTorFile(t, "geoip", ""+
+		"16777216,16777471,AU\n"+
+		"16777472,16778239,CN\n"+
+		"16778240,16779263,CN\n")
+
+	tp, err := OpenTorGeoIP(v4File, "")
+	if err != nil {
+		t.Fatalf("OpenTorGeoIP: %v", err)
+	}
+
+	cases := []struct {
+		ip   string
+		want string
+	}{
+		{"1.0.0.5", "AU"}, // 16777221, inside first range
+		{"1.0.1.1", "CN"}, // 16777473, inside second range
+		{"1.0.4.1", "CN"}, // 16778241, inside third range
+		{"8.8.8.8", ""},   // outside every range
+		{"not-an-ip", ""},
+	}
+	for _, c := range cases {
+		if got, _ := tp.GetCountry(c.ip); got != c.want {
+			t.Errorf("GetCountry(%q) = %q, want %q", c.ip, got, c.want)
+		}
+	}
+}
+
+func TestTorProviderGetCountryV6(t *testing.T) {
+	v6File := writeTorFile(t, "geoip6", ""+
+		"2001:db8::,2001:db8::ffff,AU\n"+
+		"2001:db8:1::,2001:db8:1::ffff,CN\n")
+
+	tp, err := OpenTorGeoIP("", v6File)
+	if err != nil {
+		t.Fatalf("OpenTorGeoIP: %v", err)
+	}
+
+	cases := []struct {
+		ip   string
+		want string
+	}{
+		{"2001:db8::1", "AU"},
+		{"2001:db8:1::1", "CN"},
+		{"2001:db8:2::1", ""},
+	}
+	for _, c := range cases {
+		if got, _ := tp.GetCountryV6(c.ip); got != c.want {
+			t.Errorf("GetCountryV6(%q) = %q, want %q", c.ip, got, c.want)
+		}
+	}
+}
+
+func TestTorProviderGetRecordUnsupported(t *testing.T) {
+	tp := &TorProvider{}
+	if _, err := tp.GetRecord("1.2.3.4"); err == nil {
+		t.Fatal("GetRecord: expected an error, got nil")
+	}
+}
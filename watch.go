@@ -0,0 +1,209 @@
+package geoip
+
+// Hot-reload support: a background goroutine that notices when MaxMind
+// publishes a monthly update to the database file on disk and swaps the
+// new data in, so long-running servers don't need to restart to pick it
+// up.
+
+/*
+#cgo CFLAGS: -I/opt/local/include -I/usr/local/include -I/usr/include
+#cgo LDFLAGS: -lGeoIP -L/opt/local/lib -L/usr/local/lib -L/usr/lib
+#include <GeoIP.h>
+*/
+import "C"
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"time"
+	"unsafe"
+)
+
+// Options controls how Open behaves.
+type Options struct {
+	// Watch, if true, starts a background goroutine that reloads the
+	// database when its file changes on disk. See (*GeoIP).Watch. Setting
+	// this means the resulting GeoIP MUST be closed with Close once it's
+	// no longer needed, since the watch goroutine otherwise keeps it (and
+	// its database handle) alive forever.
+	Watch bool
+
+	// Interval is how often to check the database file's mtime. Defaults
+	// to time.Minute if zero.
+	Interval time.Duration
+}
+
+// OpenWithOptions is like Open, but also lets the caller ask for
+// hot-reloading via Options.Watch.
+//
+// IMPORTANT: with Options.Watch set, the returned *GeoIP MUST be closed
+// with Close when it's no longer needed. Watch's background goroutine
+// holds a reference to the GeoIP for as long as it runs, which keeps it
+// reachable and prevents the runtime.SetFinalizer set up by Open from ever
+// firing — unlike every other GeoIP, a watching one is not cleaned up by
+// the garbage collector alone, and skipping Close leaks the goroutine and
+// its underlying database handle for the life of the process.
+func OpenWithOptions(opts Options, files ...string) (*GeoIP, error) {
+	g, err := Open(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Watch {
+		interval := opts.Interval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		g.Watch(interval)
+	}
+
+	return g, nil
+}
+
+// Watch starts a background goroutine that checks the loaded database
+// file's mtime every interval, and reloads it in place when it advances.
+// Each GeoIP tracks its own file's timestamp, so a process that opens
+// separate GeoIP values for its country, ASN and city databases reloads
+// each of them independently. Calling Watch on a GeoIP that's already
+// watching is a no-op.
+//
+// IMPORTANT: the goroutine started here holds gi reachable for as long as
+// it runs, which defeats the runtime.SetFinalizer set up by Open. You MUST
+// call Close once you're done with gi, or the goroutine and the database
+// handle it holds leak for the life of the process.
+func (gi *GeoIP) Watch(interval time.Duration) {
+	gi.mu.Lock()
+	if gi.stopWatch != nil || gi.path == "" {
+		gi.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	gi.stopWatch = stop
+	gi.watchDone = done
+	gi.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				gi.reloadIfChanged()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops any background watch goroutine, waiting for it to finish a
+// reload already in flight, and releases the underlying database handle(s).
+// The GeoIP must not be used after Close returns.
+func (gi *GeoIP) Close() {
+	gi.mu.Lock()
+	stop := gi.stopWatch
+	done := gi.watchDone
+	gi.stopWatch = nil
+	gi.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	// Free under the same lock every Get* method holds for its cgo/mmdb
+	// call, so Close can't delete gi.db/gi.mmdb out from under a lookup
+	// that's still in flight. Mark gi closed first so a reload that lost
+	// the race against the watch goroutine's exit discards the database
+	// handle it just opened instead of installing it into a gi that's
+	// already being torn down, and disarm the finalizer so the GC doesn't
+	// free gi.db/gi.mmdb a second time once gi becomes unreachable.
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+	gi.closed = true
+	runtime.SetFinalizer(gi, nil)
+	gi.free()
+}
+
+// reloadIfChanged is the body of the watch loop, split out as its own
+// helper so a panic while reloading doesn't leave gi.mu held: each reload
+// path takes the lock itself and releases it with a defer.
+func (gi *GeoIP) reloadIfChanged() {
+	gi.mu.Lock()
+	path := gi.path
+	loadedAt := gi.loadedAt
+	isMMDB := gi.mmdb != nil
+	gi.mu.Unlock()
+
+	if path == "" {
+		return
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		log.Printf("geoip: couldn't stat %s for reload: %v", path, err)
+		return
+	}
+	if !fi.ModTime().After(loadedAt) {
+		return
+	}
+
+	if isMMDB {
+		gi.reloadMMDB(path, fi.ModTime())
+		return
+	}
+	gi.reloadLegacy(path, fi.ModTime())
+}
+
+func (gi *GeoIP) reloadLegacy(path string, modTime time.Time) {
+	cbase := C.CString(path)
+	defer C.free(unsafe.Pointer(cbase))
+
+	newDB, err := C.GeoIP_open(cbase, C.GEOIP_MEMORY_CACHE)
+	if newDB == nil || err != nil {
+		log.Printf("geoip: failed to reload %s: %v", path, err)
+		return
+	}
+	C.GeoIP_set_charset(newDB, C.GEOIP_CHARSET_UTF8)
+
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+
+	if gi.closed {
+		C.GeoIP_delete(newDB)
+		return
+	}
+
+	old := gi.db
+	gi.db = newDB
+	gi.loadedAt = modTime
+	if old != nil {
+		C.GeoIP_delete(old)
+	}
+}
+
+func (gi *GeoIP) reloadMMDB(path string, modTime time.Time) {
+	newMMDB, err := openMMDB(path)
+	if err != nil {
+		log.Printf("geoip: failed to reload %s: %v", path, err)
+		return
+	}
+
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+
+	if gi.closed {
+		newMMDB.close()
+		return
+	}
+
+	old := gi.mmdb
+	gi.mmdb = newMMDB
+	gi.loadedAt = modTime
+	if old != nil {
+		old.close()
+	}
+}
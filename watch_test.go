@@ -0,0 +1,45 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWatchCloseDoesNotRace drives the watch goroutine hard while closing,
+// to catch the double-free that used to happen when Close raced a reload or
+// left the finalizer from Open armed to free gi.db/gi.mmdb a second time.
+// Run with -race to get the most out of it.
+func TestWatchCloseDoesNotRace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-City-Test.mmdb")
+	data, err := os.ReadFile(buildTestCityDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	gi, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	gi.Watch(time.Millisecond)
+
+	for i := 0; i < 50; i++ {
+		os.Chtimes(path, time.Now(), time.Now())
+		gi.reloadIfChanged()
+	}
+
+	gi.Close()
+
+	if gi.db != nil || gi.mmdb != nil {
+		t.Error("Close did not clear gi.db/gi.mmdb")
+	}
+
+	// A second Close must be safe: this is what the finalizer set up by
+	// Open would otherwise trigger (a double free) once gi became
+	// unreachable.
+	gi.Close()
+}